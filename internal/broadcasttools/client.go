@@ -0,0 +1,311 @@
+package broadcasttools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/config"
+	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// DefaultTimeout bounds the overall budget for a single session-aware
+// call (login plus the request itself) when the user hasn't configured
+// one.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultDialTimeout bounds the login request on its own, carved out of
+// the overall Timeout budget.
+const DefaultDialTimeout = 3 * time.Second
+
+// DefaultMaxReauthAttempts bounds how many times WithSession will
+// re-login and retry after the appliance invalidates our session
+// cookie.
+const DefaultMaxReauthAttempts = 3
+
+// reauthBackoff is the base delay between reauth attempts; it doubles
+// on each subsequent attempt. It's a var, not a const, so tests can
+// shrink it rather than actually waiting out the backoff.
+var reauthBackoff = 500 * time.Millisecond
+
+// ErrAuthRequired indicates the appliance rejected or ignored our
+// session cookie (redirect to the login page, or a 401) and a fresh
+// login is needed before retrying. Callers that recognize additional,
+// protocol-specific signs of an expired session (e.g. a body that
+// doesn't parse as the JSON they expect) should return this error too,
+// so WithSession reauths on it the same way.
+var ErrAuthRequired = errors.New("broadcasttools: session expired or invalid")
+
+// Config holds the connection options shared by the broadcasttools
+// input and output plugins.
+type Config struct {
+	User     string
+	Password string
+
+	CredentialsFile string `toml:"credentials_file"`
+
+	tlsint.ClientConfig
+
+	Timeout           config.Duration `toml:"timeout"`
+	DialTimeout       config.Duration `toml:"dial_timeout"`
+	MaxReauthAttempts int             `toml:"max_reauth_attempts"`
+
+	creds *credentialsFile
+}
+
+func (c *Config) init() error {
+	if c.Timeout <= 0 {
+		c.Timeout = config.Duration(DefaultTimeout)
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = config.Duration(DefaultDialTimeout)
+	}
+	if c.MaxReauthAttempts <= 0 {
+		c.MaxReauthAttempts = DefaultMaxReauthAttempts
+	}
+	if c.CredentialsFile != "" && c.creds == nil {
+		creds, err := loadCredentialsFile(c.CredentialsFile)
+		if err != nil {
+			return err
+		}
+		c.creds = creds
+	}
+	return nil
+}
+
+// Client talks to a single Broadcast Tools appliance over HTTP(S),
+// handling login, logout, and transparent reauth. Deadline tracking is
+// centralized in setDeadline so that a login and the call it's backing
+// share one overall budget while each still gets its own effective
+// per-call cutoff; setDeadline stops and replaces the client's previous
+// timer under a mutex so concurrent callers can't race on it.
+type Client struct {
+	cfg    *Config
+	driver Driver
+	base   *url.URL
+	c      *http.Client
+	ck     *http.Cookie
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+
+	reauthTotal selfstat.Stat
+}
+
+// WithTimeout derives ctx bounded by cfg.Timeout, falling back to
+// DefaultTimeout when the caller hasn't set one. Callers building the
+// top-level context around a single Gather, Connect, Close, or relay
+// write should use this instead of wrapping context.Background()
+// directly, so an unset Timeout means "use the default" rather than
+// "time out immediately".
+func WithTimeout(ctx context.Context, cfg *Config) (context.Context, context.CancelFunc) {
+	timeout := time.Duration(cfg.Timeout)
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// NewClient resolves driverName from the registry, dials rawURL, and
+// logs in once before returning.
+func NewClient(ctx context.Context, rawURL string, driverName string, cfg *Config) (*Client, error) {
+	if err := cfg.init(); err != nil {
+		return nil, err
+	}
+
+	driver, err := NewDriver(driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg, err := cfg.ClientConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		cfg:    cfg,
+		driver: driver,
+		base:   base,
+		c: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsCfg,
+				Proxy:           http.ProxyFromEnvironment,
+			},
+			// The login page is served over a redirect when our cookie
+			// is missing or stale; surface that as a response instead
+			// of silently following it so callers can recognize the
+			// session has expired.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		reauthTotal: selfstat.Register("broadcasttools", "reauth_total", map[string]string{"url": rawURL}),
+	}
+
+	// Dial establishes its own deadline via setDeadline; don't wrap ctx
+	// here too, or the child context setDeadline derives below would be
+	// parented on one that setDeadline itself cancels first.
+	if err := client.Dial(ctx); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Driver returns the driver this client was built with.
+func (c *Client) Driver() Driver {
+	return c.driver
+}
+
+// setDeadline derives a child context bounded by timeout (but never
+// beyond parent's own deadline), stopping and replacing any
+// cancellation this client previously scheduled so concurrent callers
+// can't race on it.
+func (c *Client) setDeadline(parent context.Context, timeout time.Duration) context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	c.cancel = cancel
+	return ctx
+}
+
+// Send issues a single request to path, attaching the session cookie
+// when sendCookie is set.
+func (c *Client) Send(ctx context.Context, method, path string, body io.Reader, sendCookie bool) (*http.Response, error) {
+	u := *c.base
+	u.Path = path
+
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if sendCookie {
+		r.AddCookie(c.ck)
+	}
+	if method == http.MethodPost {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	return c.c.Do(r)
+}
+
+func (c *Client) resolvePassword() (string, error) {
+	if c.cfg.creds == nil {
+		return c.cfg.Password, nil
+	}
+
+	if err := c.cfg.creds.reload(); err != nil {
+		return "", err
+	}
+	if p, ok := c.cfg.creds.password(c.cfg.User); ok {
+		return p, nil
+	}
+	if c.cfg.Password != "" && !c.cfg.creds.verify(c.cfg.User, c.cfg.Password) {
+		return "", fmt.Errorf("broadcasttools: password for %q does not match credentials file", c.cfg.User)
+	}
+	return c.cfg.Password, nil
+}
+
+// Dial logs in, storing the session cookie the appliance returns.
+func (c *Client) Dial(ctx context.Context) error {
+	if c.ck != nil {
+		return errors.New("already logged in")
+	}
+
+	ctx = c.setDeadline(ctx, time.Duration(c.cfg.DialTimeout))
+
+	password, err := c.resolvePassword()
+	if err != nil {
+		return err
+	}
+
+	v := url.Values{}
+	v.Set("AccessVal", "")
+	v.Set("LoginUser", c.cfg.User)
+	v.Set("LoginPass", password)
+
+	r, err := c.Send(ctx, http.MethodPost, c.driver.LoginPath(), strings.NewReader(v.Encode()), false)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	if r.StatusCode != http.StatusOK {
+		return errors.New("authentication failed")
+	}
+
+	cks := r.Cookies()
+	if len(cks) < 1 {
+		return errors.New("no cookies returned")
+	}
+	c.ck = cks[0]
+
+	return nil
+}
+
+// Close logs out of the current session.
+func (c *Client) Close(ctx context.Context) error {
+	ctx = c.setDeadline(ctx, time.Duration(c.cfg.DialTimeout))
+
+	v := url.Values{}
+	v.Set("Logout", "1")
+
+	_, err := c.Send(ctx, http.MethodPost, "/cgi-bin/postlogout.cgi", strings.NewReader(v.Encode()), true)
+	if err != nil {
+		return nil // ignore logout errors
+	}
+
+	c.ck = nil
+	return nil
+}
+
+// reauth clears the current session and logs back in, backing off
+// exponentially between attempts so a flapping appliance isn't hammered
+// with login requests.
+func (c *Client) reauth(ctx context.Context, attempt int) error {
+	c.ck = nil
+	c.reauthTotal.Incr(1)
+
+	if attempt > 1 {
+		backoff := reauthBackoff * time.Duration(1<<uint(attempt-2))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return c.Dial(ctx)
+}
+
+// WithSession runs fn, and if it returns ErrAuthRequired, transparently
+// logs back in and retries fn, up to cfg.MaxReauthAttempts times with
+// exponential backoff between attempts.
+func (c *Client) WithSession(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := fn(ctx)
+
+	for attempt := 1; errors.Is(err, ErrAuthRequired) && attempt <= c.cfg.MaxReauthAttempts; attempt++ {
+		if rerr := c.reauth(ctx, attempt); rerr != nil {
+			return fmt.Errorf("reauth: %w", rerr)
+		}
+		err = fn(ctx)
+	}
+
+	return err
+}
@@ -0,0 +1,182 @@
+package broadcasttools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// loginServer is a minimal stand-in for a Broadcast Tools appliance: it
+// issues a new session cookie on every login and can be told to reject
+// the next rejectNext authenticated requests, to exercise WithSession's
+// reauth path.
+type loginServer struct {
+	mu         sync.Mutex
+	logins     int
+	cookie     string
+	rejectNext int
+
+	gatherPaths []string
+}
+
+func newLoginServer() *loginServer {
+	return &loginServer{}
+}
+
+func (s *loginServer) handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/postauth.cgi":
+			s.mu.Lock()
+			s.logins++
+			s.cookie = "tok" + strconv.Itoa(s.logins)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: s.cookie})
+			s.mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		case "/cgi-bin/getexchanger_monitor.cgi":
+			s.mu.Lock()
+			s.gatherPaths = append(s.gatherPaths, r.URL.Path)
+			reject := s.rejectNext > 0
+			if reject {
+				s.rejectNext--
+			}
+			ck, _ := r.Cookie("session")
+			valid := ck != nil && ck.Value == s.cookie
+			s.mu.Unlock()
+
+			if reject || !valid {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"values":{}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server) *Client {
+	t.Helper()
+
+	cfg := &Config{User: "admin", Password: "secret"}
+	client, err := NewClient(context.Background(), srv.URL, "webexchanger", cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+// TestSendUsesRequestedPath guards against a regression where Send sent
+// every request to "/" regardless of the path it was given.
+func TestSendUsesRequestedPath(t *testing.T) {
+	ls := newLoginServer()
+	srv := httptest.NewServer(ls.handler())
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+
+	r, err := client.Send(context.Background(), http.MethodGet, client.Driver().GatherPath(), nil, true)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	r.Body.Close()
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if len(ls.gatherPaths) != 1 || ls.gatherPaths[0] != "/cgi-bin/getexchanger_monitor.cgi" {
+		t.Fatalf("server saw paths %v, want [/cgi-bin/getexchanger_monitor.cgi]", ls.gatherPaths)
+	}
+}
+
+// TestWithSessionReauthsOnExpiry exercises the reauth-with-backoff loop:
+// the first gather is rejected as if the session had expired, and
+// WithSession is expected to log back in and retry transparently.
+func TestWithSessionReauthsOnExpiry(t *testing.T) {
+	old := reauthBackoff
+	reauthBackoff = time.Millisecond
+	defer func() { reauthBackoff = old }()
+
+	ls := newLoginServer()
+	srv := httptest.NewServer(ls.handler())
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	ls.mu.Lock()
+	ls.rejectNext = 1
+	ls.mu.Unlock()
+
+	var values map[string]interface{}
+	err := client.WithSession(context.Background(), func(ctx context.Context) error {
+		r, err := client.Send(ctx, http.MethodGet, client.Driver().GatherPath(), nil, true)
+		if err != nil {
+			return err
+		}
+		defer r.Body.Close()
+		if r.StatusCode == http.StatusUnauthorized {
+			return ErrAuthRequired
+		}
+		values = map[string]interface{}{"ok": true}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithSession: %v", err)
+	}
+	if values == nil {
+		t.Fatal("WithSession returned before fn ever succeeded")
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.logins != 2 {
+		t.Fatalf("logins = %d, want 2 (initial dial + one reauth)", ls.logins)
+	}
+}
+
+// TestWithSessionGivesUpAfterMaxReauthAttempts confirms the retry loop
+// is bounded rather than reauthing forever against a wedged appliance.
+func TestWithSessionGivesUpAfterMaxReauthAttempts(t *testing.T) {
+	old := reauthBackoff
+	reauthBackoff = time.Millisecond
+	defer func() { reauthBackoff = old }()
+
+	ls := newLoginServer()
+	srv := httptest.NewServer(ls.handler())
+	defer srv.Close()
+
+	cfg := &Config{User: "admin", Password: "secret", MaxReauthAttempts: 2}
+	client, err := NewClient(context.Background(), srv.URL, "webexchanger", cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	ls.mu.Lock()
+	ls.rejectNext = 1 << 30 // always reject, simulating a wedged appliance
+	ls.mu.Unlock()
+
+	err = client.WithSession(context.Background(), func(ctx context.Context) error {
+		r, err := client.Send(ctx, http.MethodGet, client.Driver().GatherPath(), nil, true)
+		if err != nil {
+			return err
+		}
+		defer r.Body.Close()
+		if r.StatusCode == http.StatusUnauthorized {
+			return ErrAuthRequired
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("WithSession succeeded against an appliance that always rejects the session")
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	// One dial in NewClient plus cfg.MaxReauthAttempts reauths.
+	if ls.logins != 1+cfg.MaxReauthAttempts {
+		t.Fatalf("logins = %d, want %d", ls.logins, 1+cfg.MaxReauthAttempts)
+	}
+}
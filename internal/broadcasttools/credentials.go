@@ -0,0 +1,112 @@
+package broadcasttools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tg123/go-htpasswd"
+)
+
+// plainPrefix marks an htpasswd(5) entry that stores its secret
+// un-hashed. Telegraf needs the appliance's actual login password, not
+// just something to verify a guess against, so only plain entries can
+// supply it; bcrypt, SHA, MD5 and crypt entries are still accepted, but
+// only for verifying a Password configured elsewhere.
+const plainPrefix = "$plain$"
+
+// credentialsFile resolves a device's login password from an
+// htpasswd-style file instead of a literal Password in telegraf.conf.
+// It's reloaded under mu each time it's consulted so rotating the
+// appliance's password is just rewriting the file -- no Telegraf
+// restart required.
+type credentialsFile struct {
+	path string
+
+	mu    sync.Mutex
+	auth  *htpasswd.File
+	plain map[string]string
+}
+
+func loadCredentialsFile(path string) (*credentialsFile, error) {
+	cf := &credentialsFile{path: path}
+	if err := cf.reload(); err != nil {
+		return nil, err
+	}
+	return cf, nil
+}
+
+// reload re-reads the file, replacing both the hashed-entry matcher and
+// the plaintext substitutes in one mutex-guarded swap.
+func (cf *credentialsFile) reload() error {
+	auth, err := htpasswd.New(cf.path, htpasswd.DefaultSystems, nil)
+	if err != nil {
+		return fmt.Errorf("broadcasttools: reading credentials file: %w", err)
+	}
+
+	plain, err := parsePlainEntries(cf.path)
+	if err != nil {
+		return fmt.Errorf("broadcasttools: reading credentials file: %w", err)
+	}
+
+	cf.mu.Lock()
+	cf.auth = auth
+	cf.plain = plain
+	cf.mu.Unlock()
+
+	return nil
+}
+
+// password returns user's plaintext substitute, if the file has one.
+func (cf *credentialsFile) password(user string) (string, bool) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	p, ok := cf.plain[user]
+	return p, ok
+}
+
+// verify reports whether password matches user's stored entry, hashed
+// or plain.
+func (cf *credentialsFile) verify(user, password string) bool {
+	cf.mu.Lock()
+	auth := cf.auth
+	plain, isPlain := cf.plain[user]
+	cf.mu.Unlock()
+
+	if isPlain {
+		return plain == password
+	}
+	if auth == nil {
+		return false
+	}
+	return auth.Match(user, password)
+}
+
+func parsePlainEntries(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make(map[string]string)
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		user, secret, ok := strings.Cut(line, ":")
+		if !ok || !strings.HasPrefix(secret, plainPrefix) {
+			continue
+		}
+		entries[user] = strings.TrimPrefix(secret, plainPrefix)
+	}
+
+	return entries, s.Err()
+}
@@ -0,0 +1,91 @@
+package broadcasttools
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCredentialsFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "broadcasttools.htpasswd")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("writing credentials file: %v", err)
+	}
+	return path
+}
+
+func TestCredentialsFilePlainEntryResolvesPassword(t *testing.T) {
+	path := writeCredentialsFile(t, "admin:$plain$hunter2\n")
+
+	cf, err := loadCredentialsFile(path)
+	if err != nil {
+		t.Fatalf("loadCredentialsFile: %v", err)
+	}
+
+	p, ok := cf.password("admin")
+	if !ok || p != "hunter2" {
+		t.Fatalf("password(admin) = %q, %v; want \"hunter2\", true", p, ok)
+	}
+}
+
+func TestCredentialsFileHashOnlyEntryVerifiesConfiguredPassword(t *testing.T) {
+	sum := sha1.Sum([]byte("hunter2"))
+	path := writeCredentialsFile(t, "admin:{SHA}"+base64.StdEncoding.EncodeToString(sum[:])+"\n")
+
+	cf, err := loadCredentialsFile(path)
+	if err != nil {
+		t.Fatalf("loadCredentialsFile: %v", err)
+	}
+
+	if _, ok := cf.password("admin"); ok {
+		t.Fatal("password(admin) reported a plaintext substitute for a hash-only entry")
+	}
+	if !cf.verify("admin", "hunter2") {
+		t.Fatal("verify(admin, hunter2) = false, want true")
+	}
+	if cf.verify("admin", "wrong") {
+		t.Fatal("verify(admin, wrong) = true, want false")
+	}
+}
+
+// TestResolvePasswordErrorsOnMismatch covers the error
+// resolvePassword returns when a configured Password doesn't match
+// what's in the credentials file for that user.
+func TestResolvePasswordErrorsOnMismatch(t *testing.T) {
+	path := writeCredentialsFile(t, "admin:hashedvalue\n")
+
+	cfg := &Config{User: "admin", Password: "wrong", CredentialsFile: path}
+	if err := cfg.init(); err != nil {
+		t.Fatalf("cfg.init: %v", err)
+	}
+
+	c := &Client{cfg: cfg}
+	if _, err := c.resolvePassword(); err == nil {
+		t.Fatal("resolvePassword: expected an error for a Password that doesn't match the credentials file")
+	}
+}
+
+func TestCredentialsFileReloadPicksUpRewrittenFile(t *testing.T) {
+	path := writeCredentialsFile(t, "admin:$plain$hunter2\n")
+
+	cf, err := loadCredentialsFile(path)
+	if err != nil {
+		t.Fatalf("loadCredentialsFile: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("admin:$plain$newpass\n"), 0o600); err != nil {
+		t.Fatalf("rewriting credentials file: %v", err)
+	}
+	if err := cf.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	p, ok := cf.password("admin")
+	if !ok || p != "newpass" {
+		t.Fatalf("password(admin) after reload = %q, %v; want \"newpass\", true", p, ok)
+	}
+}
@@ -0,0 +1,101 @@
+// Package broadcasttools holds the session, driver-registry, and
+// credential-handling code shared by the broadcasttools input and
+// output plugins, so a login or a relay write looks the same from
+// either direction.
+package broadcasttools
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Driver describes how to talk to one family of Broadcast Tools
+// hardware: where to log in, where to poll for telemetry, where to
+// actuate a relay, and how to turn its JSON body into Telegraf fields.
+// Built-in drivers are registered via RegisterDriver in the init() of
+// their own driver_*.go file, the same pattern inputs.Add uses to register plugins;
+// out-of-tree code can call RegisterDriver itself to support additional
+// models without touching this package.
+type Driver interface {
+	// LoginPath is the CGI endpoint used to establish a session.
+	LoginPath() string
+	// GatherPath is the CGI endpoint polled for telemetry.
+	GatherPath() string
+	// Parse turns the "values" object of a decoded monitor response
+	// into flat Telegraf fields.
+	Parse(values map[string]interface{}) (map[string]interface{}, error)
+
+	// RelayPath is the CGI endpoint used to actuate a relay.
+	RelayPath() string
+	// RelayForm builds the POST body that sets relay index to state.
+	RelayForm(index int, state bool) url.Values
+}
+
+// FieldParser extracts the value of one indexed field (e.g. the 3rd
+// temperature probe) from a decoded "values" object.
+type FieldParser func(values map[string]interface{}, index int) interface{}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]func() Driver)
+)
+
+// RegisterDriver makes a Driver factory available under name for the
+// `model` config option. Call it from an init() func, mirroring
+// inputs.Add.
+func RegisterDriver(name string, factory func() Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[name] = factory
+}
+
+// NewDriver looks up the driver registered under name.
+func NewDriver(name string) (Driver, error) {
+	driversMu.RLock()
+	factory, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("broadcasttools: unknown model %q", name)
+	}
+	return factory(), nil
+}
+
+// ParseFields walks values, matching each key against fields' patterns
+// and invoking the corresponding parser to build a flat field set.
+// Every built-in driver shares this walk; adding a new model is just a
+// new field table, not new parsing logic.
+func ParseFields(values map[string]interface{}, fields map[*regexp.Regexp]FieldParser) map[string]interface{} {
+	out := make(map[string]interface{})
+
+	for key, name := range values {
+		label, ok := name.(string)
+		if !ok {
+			continue
+		}
+
+		for re, parse := range fields {
+			matches := re.FindStringSubmatch(key)
+			if len(matches) < 2 {
+				continue
+			}
+			index, err := strconv.Atoi(matches[1])
+			if err != nil {
+				continue
+			}
+			out[Keyify(label)] = parse(values, index)
+		}
+	}
+
+	return out
+}
+
+// Keyify turns a device-reported label (e.g. "Xmtr On") into a Telegraf
+// field name (e.g. "xmtr_on").
+func Keyify(s string) string {
+	s = strings.ToLower(s)
+	return strings.Replace(s, " ", "_", -1)
+}
@@ -0,0 +1,41 @@
+package broadcasttools
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// siteSentinelDriver supports the Site Sentinel line, a relay-only
+// remote control unit with no temperature, meter, or status telemetry.
+type siteSentinelDriver struct{}
+
+var siteSentinelFields = map[*regexp.Regexp]FieldParser{
+	regexp.MustCompile(`^R2(\d+)$`): func(values map[string]interface{}, index int) interface{} {
+		return values[fmt.Sprintf("RelayIndicator%02d", index)]
+	},
+}
+
+func (siteSentinelDriver) LoginPath() string  { return "/cgi-bin/postauth.cgi" }
+func (siteSentinelDriver) GatherPath() string { return "/cgi-bin/getsentinel_status.cgi" }
+func (siteSentinelDriver) RelayPath() string  { return "/cgi-bin/postsentinel_relay.cgi" }
+
+func (siteSentinelDriver) Parse(values map[string]interface{}) (map[string]interface{}, error) {
+	return ParseFields(values, siteSentinelFields), nil
+}
+
+func (siteSentinelDriver) RelayForm(index int, state bool) url.Values {
+	v := url.Values{}
+	v.Set("RelayNum", strconv.Itoa(index))
+	if state {
+		v.Set("RelayCmd", "1")
+	} else {
+		v.Set("RelayCmd", "0")
+	}
+	return v
+}
+
+func init() {
+	RegisterDriver("sitesentinel", func() Driver { return siteSentinelDriver{} })
+}
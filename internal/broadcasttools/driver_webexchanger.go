@@ -0,0 +1,57 @@
+package broadcasttools
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// webExchangerDriver supports the WVRC-8 / Web Exchanger family, the
+// product this plugin originally shipped against.
+type webExchangerDriver struct{}
+
+var webExchangerFields = map[*regexp.Regexp]FieldParser{
+	regexp.MustCompile(`^T1(\d+)$`): func(values map[string]interface{}, index int) interface{} {
+		t := values[fmt.Sprintf("TempValue%02d", index)].(string)
+		t = strings.TrimSuffix(t, " *F")
+		v, _ := strconv.Atoi(t)
+		return v
+	},
+	regexp.MustCompile(`^M1(\d+)$`): func(values map[string]interface{}, index int) interface{} {
+		return values[fmt.Sprintf("MeterValue%02d", index)]
+	},
+	regexp.MustCompile(`^VCLabel(\d+)$`): func(values map[string]interface{}, index int) interface{} {
+		return values[fmt.Sprintf("VCValue%02d", index)]
+	},
+	regexp.MustCompile(`^S1(\d+)$`): func(values map[string]interface{}, index int) interface{} {
+		return values[fmt.Sprintf("StatusIndicator%02d", index)]
+	},
+	regexp.MustCompile(`^R2(\d+)$`): func(values map[string]interface{}, index int) interface{} {
+		return values[fmt.Sprintf("RelayIndicator%02d", index)]
+	},
+}
+
+func (webExchangerDriver) LoginPath() string  { return "/cgi-bin/postauth.cgi" }
+func (webExchangerDriver) GatherPath() string { return "/cgi-bin/getexchanger_monitor.cgi" }
+func (webExchangerDriver) RelayPath() string  { return "/cgi-bin/postrelay.cgi" }
+
+func (webExchangerDriver) Parse(values map[string]interface{}) (map[string]interface{}, error) {
+	return ParseFields(values, webExchangerFields), nil
+}
+
+func (webExchangerDriver) RelayForm(index int, state bool) url.Values {
+	v := url.Values{}
+	v.Set("RelayNum", strconv.Itoa(index))
+	if state {
+		v.Set("RelayCmd", "1")
+	} else {
+		v.Set("RelayCmd", "0")
+	}
+	return v
+}
+
+func init() {
+	RegisterDriver("webexchanger", func() Driver { return webExchangerDriver{} })
+}
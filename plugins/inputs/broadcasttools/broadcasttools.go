@@ -1,65 +1,34 @@
 package broadcasttools
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
-	"regexp"
-	"strconv"
-	"strings"
 	"sync"
 
 	"github.com/influxdata/telegraf"
+	btclient "github.com/influxdata/telegraf/internal/broadcasttools"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
 
-type parser func(src map[string]interface{}, index int) interface{}
-
-var (
-	regexpTemp   = regexp.MustCompile(`^T1(\d+)$`)
-	regexpMeter  = regexp.MustCompile(`^M1(\d+)$`)
-	regexpVC     = regexp.MustCompile(`^VCLabel(\d+)$`)
-	regexpStatus = regexp.MustCompile(`^S1(\d+)$`)
-	regexpRelay  = regexp.MustCompile(`^R2(\d+)$`)
-
-	parsers = map[*regexp.Regexp]parser{
-		regexpTemp: func(src map[string]interface{}, index int) interface{} {
-			t := src[fmt.Sprintf("TempValue%02d", index)].(string)
-			t = strings.TrimSuffix(t, " *F")
-			v, _ := strconv.Atoi(t)
-			return v
-		},
-		regexpMeter: func(src map[string]interface{}, index int) interface{} {
-			return src[fmt.Sprintf("MeterValue%02d", index)]
-		},
-		regexpVC: func(src map[string]interface{}, index int) interface{} {
-			return src[fmt.Sprintf("VCValue%02d", index)]
-		},
-		regexpStatus: func(src map[string]interface{}, index int) interface{} {
-			return src[fmt.Sprintf("StatusIndicator%02d", index)]
-		},
-		regexpRelay: func(src map[string]interface{}, index int) interface{} {
-			return src[fmt.Sprintf("RelayIndicator%02d", index)]
-		},
-	}
-)
+// defaultModel is the driver selected when the user doesn't set one,
+// preserving this plugin's original WVRC-8 / Web Exchanger behavior.
+const defaultModel = "webexchanger"
 
 type BroadcastTools struct {
-	URLs     []string
-	User     string
-	Password string
+	URLs  []string
+	Model string `toml:"model"`
+
+	btclient.Config
 
 	devices     []Device
 	initialized bool
 }
 
 type Device interface {
-	Dial() error
-	Close() error
-	Gather(acc telegraf.Accumulator) error
+	Gather(ctx context.Context, acc telegraf.Accumulator) error
+	Close(ctx context.Context) error
 }
 
 const sampleConfig = `
@@ -70,6 +39,31 @@ const sampleConfig = `
   user = "admin"
   ## Password
   password = "password"
+  ## Overall budget for a single gather (login + fetch). 0 uses the
+  ## built-in default.
+  # timeout = "5s"
+  ## Budget for the login request alone, carved out of timeout above.
+  # dial_timeout = "3s"
+  ## Number of times to transparently re-login and retry a gather after
+  ## the appliance invalidates our session (reboot, idle timeout, a
+  ## competing login elsewhere).
+  # max_reauth_attempts = 3
+  ## Broadcast Tools product family to talk to. Built in: "webexchanger"
+  ## (WVRC-8 / Web Exchanger, the default) and "sitesentinel"
+  ## (relay-only Site Sentinel units). Out-of-tree code can register
+  ## additional models via the internal/broadcasttools driver registry.
+  # model = "webexchanger"
+  ## Optional TLS config, for appliances sitting behind a TLS-terminating
+  ## reverse proxy. HTTP_PROXY/HTTPS_PROXY are honored automatically.
+  # tls_ca = "/etc/telegraf/ca.pem"
+  # tls_cert = "/etc/telegraf/cert.pem"
+  # tls_key = "/etc/telegraf/key.pem"
+  # insecure_skip_verify = false
+  ## Optional htpasswd(5)-style file to resolve the login password from
+  ## instead of the plaintext password above; entries are re-read every
+  ## time they're needed, so rotating a password is just rewriting this
+  ## file.
+  # credentials_file = "/etc/telegraf/broadcasttools.htpasswd"
 `
 
 func (bt *BroadcastTools) init() error {
@@ -77,22 +71,19 @@ func (bt *BroadcastTools) init() error {
 		return nil
 	}
 
+	if bt.Model == "" {
+		bt.Model = defaultModel
+	}
+
 	for _, u := range bt.URLs {
-		base, err := url.Parse(u)
+		ctx, cancel := btclient.WithTimeout(context.Background(), &bt.Config)
+		client, err := btclient.NewClient(ctx, u, bt.Model, &bt.Config)
+		cancel()
 		if err != nil {
 			return err
 		}
 
-		d := &device{
-			bt:   bt,
-			base: base,
-			c:    &http.Client{},
-		}
-		if err := d.Dial(); err != nil {
-			return err
-		}
-
-		bt.devices = append(bt.devices, d)
+		bt.devices = append(bt.devices, &device{client: client})
 	}
 
 	bt.initialized = true
@@ -121,8 +112,12 @@ func (bt *BroadcastTools) Gather(acc telegraf.Accumulator) error {
 
 		go func(d Device, a telegraf.Accumulator) {
 			defer wg.Done()
-			if err := d.Gather(a); err != nil {
-				acc.AddError(err)
+
+			ctx, cancel := btclient.WithTimeout(context.Background(), &bt.Config)
+			defer cancel()
+
+			if err := d.Gather(ctx, a); err != nil {
+				a.AddError(err)
 			}
 		}(device, acc)
 	}
@@ -131,117 +126,75 @@ func (bt *BroadcastTools) Gather(acc telegraf.Accumulator) error {
 	return nil
 }
 
+// device wraps a session-aware client with the JSON parsing specific to
+// polling a Broadcast Tools appliance for telemetry.
 type device struct {
-	bt   *BroadcastTools
-	base *url.URL
-	c    *http.Client
-	ck   *http.Cookie
+	client *btclient.Client
 }
 
-func (d device) send(method string, path string, data io.Reader, sendCookie bool) (*http.Response, error) {
-	u := *d.base
-	u.RawPath = path
-
-	r, err := http.NewRequest(method, u.String(), data)
+// fetch issues the monitor request and parses its body, returning
+// btclient.ErrAuthRequired whenever the response looks like the
+// appliance bounced us to its login page rather than answering.
+func (d *device) fetch(ctx context.Context) (map[string]interface{}, error) {
+	r, err := d.client.Send(ctx, http.MethodGet, d.client.Driver().GatherPath(), nil, true)
 	if err != nil {
 		return nil, err
 	}
-	if sendCookie {
-		r.AddCookie(d.ck)
-	}
-	if method == http.MethodPost {
-		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	}
-
-	return d.c.Do(r)
-}
+	defer r.Body.Close()
 
-func (d *device) Dial() error {
-	if d.ck != nil {
-		return errors.New("already logged in")
+	switch r.StatusCode {
+	case http.StatusOK:
+	case http.StatusFound, http.StatusUnauthorized:
+		return nil, btclient.ErrAuthRequired
+	default:
+		return nil, fmt.Errorf("expected status %d; got %d", http.StatusOK, r.StatusCode)
 	}
 
-	v := url.Values{}
-	v.Set("AccessVal", "")
-	v.Set("LoginUser", d.bt.User)
-	v.Set("LoginPass", d.bt.Password)
-
-	r, err := d.send(http.MethodPost, "/cgi-bin/postauth.cgi", strings.NewReader(v.Encode()), false)
-	if err != nil {
-		return err
-	}
-	if r.StatusCode != http.StatusOK {
-		return errors.New("authentication failed")
-	}
-
-	cks := r.Cookies()
-	if len(cks) < 1 {
-		return errors.New("no cookies returned")
+	var data map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		// The login page is HTML, not JSON; a decode failure this late
+		// is almost always an expired session rather than a malformed
+		// appliance response.
+		return nil, btclient.ErrAuthRequired
 	}
-	d.ck = cks[0]
-
-	return nil
-}
 
-func (d device) Close() error {
-	v := url.Values{}
-	v.Set("Logout", "1")
-
-	_, err := d.send(http.MethodPost, "/cgi-bin/postlogout.cgi", strings.NewReader(v.Encode()), true)
-	if err != nil {
-		return nil // ignore logout errors
+	values, ok := data["values"].(map[string]interface{})
+	if !ok {
+		return nil, btclient.ErrAuthRequired
 	}
 
-	d.ck = nil
-	d.c = nil
-	return nil
+	return values, nil
 }
 
-func keyify(s string) string {
-	s = strings.ToLower(s)
-	return strings.Replace(s, " ", "_", -1)
-}
+func (d *device) Gather(ctx context.Context, acc telegraf.Accumulator) error {
+	var values map[string]interface{}
 
-func (d *device) Gather(acc telegraf.Accumulator) error {
-	r, err := d.send(http.MethodGet, "/cgi-bin/getexchanger_monitor.cgi", nil, true)
+	err := d.client.WithSession(ctx, func(ctx context.Context) error {
+		v, err := d.fetch(ctx)
+		if err != nil {
+			return err
+		}
+		values = v
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	if r.StatusCode != http.StatusOK {
-		return fmt.Errorf("expected status %d; got %d", http.StatusOK, r.StatusCode)
-	}
-	defer r.Body.Close()
 
-	var data map[string]interface{}
-
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+	fields, err := d.client.Driver().Parse(values)
+	if err != nil {
 		return err
 	}
 
-	values := data["values"].(map[string]interface{})
-
-	fields := make(map[string]interface{})
-
-	for key, name := range values {
-		for reg, parser := range parsers {
-			matches := reg.FindStringSubmatch(key)
-			if len(matches) < 2 {
-				continue
-			}
-			index, err := strconv.Atoi(matches[1])
-			if err != nil {
-				continue
-			}
-			value := parser(values, index)
-			fields[keyify(name.(string))] = value
-		}
-	}
-
 	acc.AddFields("broadcasttools", fields, nil)
 
 	return nil
 }
 
+func (d *device) Close(ctx context.Context) error {
+	return d.client.Close(ctx)
+}
+
 func init() {
 	inputs.Add("broadcasttools", func() telegraf.Input {
 		bt := &BroadcastTools{}
@@ -0,0 +1,251 @@
+package broadcasttools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	btclient "github.com/influxdata/telegraf/internal/broadcasttools"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+// defaultModel is the driver selected when the user doesn't set one.
+const defaultModel = "webexchanger"
+
+// measurementRelay is the measurement this plugin acts on; everything
+// else passed to Write is ignored.
+const measurementRelay = "broadcasttools_relay"
+
+// BroadcastTools actuates the RelayIndicator outputs a Broadcast Tools
+// appliance exposes, turning a metric like
+// `broadcasttools_relay,name=xmtr_on state=1i` into a relay write. It
+// shares its session handling with the input plugin of the same name
+// via internal/broadcasttools.
+type BroadcastTools struct {
+	// URLs lists every appliance a relay write should fan out to; all of
+	// them get the same command, so this is meant for one logical
+	// device (e.g. reachable over more than one path), not a fleet.
+	URLs  []string
+	Model string `toml:"model"`
+
+	// Relays maps a metric's `name` tag to the appliance's relay index.
+	// Writes for any other name are dropped (with a warning) so an
+	// errant metric can't toggle unrelated hardware.
+	Relays map[string]int `toml:"relays"`
+
+	// Debounce skips re-issuing an identical relay command within this
+	// interval of the last one for that relay. 0 disables debouncing.
+	Debounce config.Duration `toml:"debounce"`
+
+	// DryRun logs intended writes instead of making them.
+	DryRun bool `toml:"dry_run"`
+
+	btclient.Config
+
+	clients []*btclient.Client
+
+	mu   sync.Mutex
+	last map[string]relayWrite
+}
+
+type relayWrite struct {
+	state bool
+	at    time.Time
+}
+
+const sampleConfig = `
+  ## An array of URLs to actuate relays on. i.e.,
+  ##   http://example.com:3000
+  ## Every relay write is sent to every URL listed here, so this should
+  ## normally be a single appliance; use one [[outputs.broadcasttools]]
+  ## instance per device if you control more than one.
+  urls = ["http://localhost:1776"]
+  ## Username
+  user = "admin"
+  ## Password
+  password = "password"
+  ## Broadcast Tools product family to talk to. Built in: "webexchanger"
+  ## and "sitesentinel". Out-of-tree code can register additional
+  ## models via the internal/broadcasttools driver registry.
+  # model = "webexchanger"
+
+  ## Allow-list mapping a metric's name tag to the appliance's relay
+  ## index. Relays not listed here are never written.
+  [outputs.broadcasttools.relays]
+    # xmtr_on = 1
+    # backup_xmtr = 2
+
+  ## Skip re-issuing an identical relay command within this long of the
+  ## last one for that relay.
+  # debounce = "5s"
+  ## Log intended writes instead of making them.
+  # dry_run = false
+`
+
+func (bt *BroadcastTools) SampleConfig() string {
+	return sampleConfig
+}
+
+func (bt *BroadcastTools) Description() string {
+	return "Actuate Broadcast Tools device relays from processor-driven writes"
+}
+
+func (bt *BroadcastTools) Connect() error {
+	if bt.Model == "" {
+		bt.Model = defaultModel
+	}
+
+	for _, u := range bt.URLs {
+		ctx, cancel := btclient.WithTimeout(context.Background(), &bt.Config)
+		client, err := btclient.NewClient(ctx, u, bt.Model, &bt.Config)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		bt.clients = append(bt.clients, client)
+	}
+
+	return nil
+}
+
+func (bt *BroadcastTools) Close() error {
+	for _, c := range bt.clients {
+		ctx, cancel := btclient.WithTimeout(context.Background(), &bt.Config)
+		c.Close(ctx)
+		cancel()
+	}
+	return nil
+}
+
+func (bt *BroadcastTools) Write(metrics []telegraf.Metric) error {
+	for _, m := range metrics {
+		if m.Name() != measurementRelay {
+			continue
+		}
+
+		name, ok := m.GetTag("name")
+		if !ok {
+			continue
+		}
+
+		index, ok := bt.Relays[name]
+		if !ok {
+			log.Printf("W! [outputs.broadcasttools] ignoring relay %q: not in allow-list", name)
+			continue
+		}
+
+		raw, ok := m.GetField("state")
+		if !ok {
+			continue
+		}
+		state, err := relayStateOf(raw)
+		if err != nil {
+			log.Printf("W! [outputs.broadcasttools] ignoring relay %q: %v", name, err)
+			continue
+		}
+
+		if bt.debounced(name, state) {
+			continue
+		}
+
+		if bt.DryRun {
+			log.Printf("I! [outputs.broadcasttools] dry run: would set relay %q (index %d) to %v", name, index, state)
+			bt.recordWrite(name, state)
+			continue
+		}
+
+		if err := bt.setRelay(index, state); err != nil {
+			return fmt.Errorf("broadcasttools: setting relay %q: %w", name, err)
+		}
+		bt.recordWrite(name, state)
+	}
+
+	return nil
+}
+
+// debounced reports whether name was already set to state within the
+// configured Debounce window, so we can skip re-issuing it.
+func (bt *BroadcastTools) debounced(name string, state bool) bool {
+	if bt.Debounce <= 0 {
+		return false
+	}
+
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	prev, ok := bt.last[name]
+	return ok && prev.state == state && time.Since(prev.at) < time.Duration(bt.Debounce)
+}
+
+// recordWrite remembers that name was just set to state, so a retry of
+// the same command within the debounce window is skipped. Callers must
+// only call this once the write (or, in DryRun, the simulated write)
+// has actually gone through -- a failed setRelay must not be recorded,
+// or a retry of the same desired state would be debounced away and the
+// relay left in the wrong physical state.
+func (bt *BroadcastTools) recordWrite(name string, state bool) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	if bt.last == nil {
+		bt.last = make(map[string]relayWrite)
+	}
+	bt.last[name] = relayWrite{state: state, at: time.Now()}
+}
+
+// setRelay writes index to state on every configured device.
+func (bt *BroadcastTools) setRelay(index int, state bool) error {
+	for _, client := range bt.clients {
+		ctx, cancel := btclient.WithTimeout(context.Background(), &bt.Config)
+		err := client.WithSession(ctx, func(ctx context.Context) error {
+			form := client.Driver().RelayForm(index, state)
+
+			r, err := client.Send(ctx, http.MethodPost, client.Driver().RelayPath(), strings.NewReader(form.Encode()), true)
+			if err != nil {
+				return err
+			}
+			defer r.Body.Close()
+
+			switch r.StatusCode {
+			case http.StatusOK:
+				return nil
+			case http.StatusFound, http.StatusUnauthorized:
+				return btclient.ErrAuthRequired
+			default:
+				return fmt.Errorf("expected status %d; got %d", http.StatusOK, r.StatusCode)
+			}
+		})
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func relayStateOf(v interface{}) (bool, error) {
+	switch t := v.(type) {
+	case bool:
+		return t, nil
+	case int64:
+		return t != 0, nil
+	case float64:
+		return t != 0, nil
+	default:
+		return false, fmt.Errorf("unsupported state value %v (%T)", v, v)
+	}
+}
+
+func init() {
+	outputs.Add("broadcasttools", func() telegraf.Output {
+		return &BroadcastTools{}
+	})
+}
@@ -0,0 +1,215 @@
+package broadcasttools
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+)
+
+// testMetric is the minimal telegraf.Metric this plugin reads from. The
+// embedded interface is left nil: only Name, GetTag and GetField are
+// implemented, and calling any other method panics.
+type testMetric struct {
+	telegraf.Metric
+
+	name  string
+	tags  map[string]string
+	field interface{}
+}
+
+func (m *testMetric) Name() string { return m.name }
+
+func (m *testMetric) GetTag(key string) (string, bool) {
+	v, ok := m.tags[key]
+	return v, ok
+}
+
+func (m *testMetric) GetField(key string) (interface{}, bool) {
+	if key != "state" {
+		return nil, false
+	}
+	return m.field, true
+}
+
+func relayMetric(name string, state interface{}) telegraf.Metric {
+	return &testMetric{name: "broadcasttools_relay", tags: map[string]string{"name": name}, field: state}
+}
+
+// relayServer is a stand-in appliance that records every relay write it
+// receives.
+type relayServer struct {
+	mu       sync.Mutex
+	cookie   string
+	writes   []url.Values
+	failNext int
+}
+
+func (s *relayServer) handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/postauth.cgi":
+			s.mu.Lock()
+			s.cookie = "tok"
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: s.cookie})
+			s.mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		case "/cgi-bin/postrelay.cgi":
+			s.mu.Lock()
+			if s.failNext > 0 {
+				s.failNext--
+				s.mu.Unlock()
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			s.mu.Unlock()
+
+			body, _ := ioutil.ReadAll(r.Body)
+			form, _ := url.ParseQuery(string(body))
+
+			s.mu.Lock()
+			s.writes = append(s.writes, form)
+			s.mu.Unlock()
+
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func newTestPlugin(t *testing.T, srv *httptest.Server, relays map[string]int) *BroadcastTools {
+	t.Helper()
+
+	bt := &BroadcastTools{
+		URLs:   []string{srv.URL},
+		Relays: relays,
+	}
+	bt.User = "admin"
+	bt.Password = "secret"
+
+	if err := bt.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { bt.Close() })
+
+	return bt
+}
+
+func TestWriteIgnoresRelayNotInAllowList(t *testing.T) {
+	rs := &relayServer{}
+	srv := httptest.NewServer(rs.handler())
+	defer srv.Close()
+
+	bt := newTestPlugin(t, srv, map[string]int{"xmtr_on": 1})
+
+	if err := bt.Write([]telegraf.Metric{relayMetric("unlisted", true)}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if len(rs.writes) != 0 {
+		t.Fatalf("got %d relay writes, want 0 for an unlisted relay", len(rs.writes))
+	}
+}
+
+func TestWriteSendsRelayCommand(t *testing.T) {
+	rs := &relayServer{}
+	srv := httptest.NewServer(rs.handler())
+	defer srv.Close()
+
+	bt := newTestPlugin(t, srv, map[string]int{"xmtr_on": 1})
+
+	if err := bt.Write([]telegraf.Metric{relayMetric("xmtr_on", true)}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if len(rs.writes) != 1 {
+		t.Fatalf("got %d relay writes, want 1", len(rs.writes))
+	}
+	if got := rs.writes[0].Get("RelayNum"); got != "1" {
+		t.Errorf("RelayNum = %q, want \"1\"", got)
+	}
+	if got := rs.writes[0].Get("RelayCmd"); got != "1" {
+		t.Errorf("RelayCmd = %q, want \"1\"", got)
+	}
+}
+
+func TestWriteDebouncesRepeatedState(t *testing.T) {
+	rs := &relayServer{}
+	srv := httptest.NewServer(rs.handler())
+	defer srv.Close()
+
+	bt := newTestPlugin(t, srv, map[string]int{"xmtr_on": 1})
+	bt.Debounce = config.Duration(time.Minute)
+
+	metrics := []telegraf.Metric{relayMetric("xmtr_on", true)}
+	if err := bt.Write(metrics); err != nil {
+		t.Fatalf("Write (1st): %v", err)
+	}
+	if err := bt.Write(metrics); err != nil {
+		t.Fatalf("Write (2nd): %v", err)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if len(rs.writes) != 1 {
+		t.Fatalf("got %d relay writes, want 1 (2nd should be debounced)", len(rs.writes))
+	}
+}
+
+// TestWriteRetriesAfterFailedWrite guards against a regression where a
+// failed write was still recorded for debounce purposes, silently
+// swallowing the retry of the same desired state and leaving the relay
+// in the wrong physical state.
+func TestWriteRetriesAfterFailedWrite(t *testing.T) {
+	rs := &relayServer{failNext: 1}
+	srv := httptest.NewServer(rs.handler())
+	defer srv.Close()
+
+	bt := newTestPlugin(t, srv, map[string]int{"xmtr_on": 1})
+	bt.Debounce = config.Duration(time.Minute)
+
+	metrics := []telegraf.Metric{relayMetric("xmtr_on", true)}
+
+	if err := bt.Write(metrics); err == nil {
+		t.Fatal("Write (1st): expected the simulated 500 to surface as an error")
+	}
+	if err := bt.Write(metrics); err != nil {
+		t.Fatalf("Write (retry): %v", err)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if len(rs.writes) != 1 {
+		t.Fatalf("got %d relay writes, want 1 (the retry must not be debounced away)", len(rs.writes))
+	}
+}
+
+func TestWriteDryRunSendsNothing(t *testing.T) {
+	rs := &relayServer{}
+	srv := httptest.NewServer(rs.handler())
+	defer srv.Close()
+
+	bt := newTestPlugin(t, srv, map[string]int{"xmtr_on": 1})
+	bt.DryRun = true
+
+	if err := bt.Write([]telegraf.Metric{relayMetric("xmtr_on", true)}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if len(rs.writes) != 0 {
+		t.Fatalf("got %d relay writes, want 0 in dry-run mode", len(rs.writes))
+	}
+}